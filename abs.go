@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	EV_ABS = 0x03
+
+	ABS_X = 0x00
+	ABS_Y = 0x01
+
+	UI_SET_ABSBIT = 0x40045567
+	UI_ABS_SETUP  = 0x401c5504
+
+	// Logical screen size assumed until a client POSTs /config with the
+	// compositor's real resolution.
+	defaultScreenWidth  = 1920
+	defaultScreenHeight = 1080
+)
+
+// AbsInfo mirrors struct input_absinfo.
+type AbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// UInputAbsSetup mirrors struct uinput_abs_setup (28 bytes: a uint16
+// code, 2 bytes of compiler padding to align Info on a 4-byte
+// boundary, then the 24-byte AbsInfo).
+type UInputAbsSetup struct {
+	Code uint16
+	_    [2]byte
+	Info AbsInfo
+}
+
+type ConfigRequest struct {
+	ScreenWidth  int32 `json:"screen_width"`
+	ScreenHeight int32 `json:"screen_height"`
+}
+
+type MouseAbsoluteRequest struct {
+	X int32 `json:"x"`
+	Y int32 `json:"y"`
+}
+
+type MouseDragRequest struct {
+	Button string `json:"button"`
+	X      int32  `json:"x"`
+	Y      int32  `json:"y"`
+}
+
+// createAbsolutePointerDevice opens a second uinput device dedicated to
+// EV_ABS pointer movement, since a single device can't usefully mix
+// relative and absolute X/Y axes.
+func createAbsolutePointerDevice(width, height int32) (int, error) {
+	fd, err := syscall.Open("/dev/uinput", syscall.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return -1, fmt.Errorf("failed to open /dev/uinput for abs device: %v", err)
+	}
+
+	if err := ioctl(fd, UI_SET_EVBIT, EV_KEY); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to enable key events on abs device: %v", err)
+	}
+	if err := ioctl(fd, UI_SET_EVBIT, EV_ABS); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to enable abs events: %v", err)
+	}
+	if err := ioctl(fd, UI_SET_EVBIT, EV_SYN); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to enable sync events on abs device: %v", err)
+	}
+
+	for _, btn := range []uint16{BTN_LEFT, BTN_RIGHT, BTN_MIDDLE} {
+		if err := ioctl(fd, UI_SET_KEYBIT, uintptr(btn)); err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("failed to enable button %d on abs device: %v", btn, err)
+		}
+	}
+
+	for _, axis := range []uint16{ABS_X, ABS_Y} {
+		if err := ioctl(fd, UI_SET_ABSBIT, uintptr(axis)); err != nil {
+			syscall.Close(fd)
+			return -1, fmt.Errorf("failed to enable abs axis %d: %v", axis, err)
+		}
+	}
+
+	if err := setupAbsAxis(fd, ABS_X, width); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	if err := setupAbsAxis(fd, ABS_Y, height); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	setup := UInputSetup{
+		ID: InputID{
+			Bustype: 0x03,
+			Vendor:  0x1234,
+			Product: 0x5679,
+			Version: 1,
+		},
+	}
+	copy(setup.Name[:], "Virtual Absolute Pointer")
+
+	if err := ioctlSetup(fd, UI_DEV_SETUP, &setup); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to setup abs device: %v", err)
+	}
+
+	if err := ioctl(fd, UI_DEV_CREATE, 0); err != nil {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("failed to create abs device: %v", err)
+	}
+
+	return fd, nil
+}
+
+func setupAbsAxis(fd int, code uint16, resolution int32) error {
+	absSetup := UInputAbsSetup{
+		Code: code,
+		Info: AbsInfo{
+			Minimum:    0,
+			Maximum:    resolution - 1,
+			Resolution: resolution,
+		},
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), UI_ABS_SETUP, uintptr(unsafe.Pointer(&absSetup)))
+	if errno != 0 {
+		return fmt.Errorf("failed to configure abs axis %d: %v", code, errno)
+	}
+	return nil
+}
+
+func (vid *VirtualInputDevice) sendAbsEvent(eventType, code uint16, value int32) error {
+	event := InputEvent{
+		Time:  syscall.NsecToTimeval(time.Now().UnixNano()),
+		Type:  eventType,
+		Code:  code,
+		Value: value,
+	}
+
+	vid.absMu.RLock()
+	fd := vid.absFd
+	vid.absMu.RUnlock()
+
+	_, err := syscall.Write(fd, (*(*[unsafe.Sizeof(event)]byte)(unsafe.Pointer(&event)))[:])
+	return err
+}
+
+// MoveMouseAbsolute jumps the cursor to an absolute screen coordinate,
+// unlike MoveMouse which only emits relative deltas.
+func (vid *VirtualInputDevice) MoveMouseAbsolute(x, y int32) error {
+	if err := vid.sendAbsEvent(EV_ABS, ABS_X, x); err != nil {
+		return err
+	}
+	if err := vid.sendAbsEvent(EV_ABS, ABS_Y, y); err != nil {
+		return err
+	}
+	return vid.sendAbsEvent(EV_SYN, 0, 0)
+}
+
+// DragMouse presses button, jumps to (x, y), then releases button —
+// a scripted sequence of sendAbsEvent calls with a single trailing
+// EV_SYN.
+func (vid *VirtualInputDevice) DragMouse(button string, x, y int32) error {
+	var btn uint16
+	switch strings.ToLower(button) {
+	case "left", "":
+		btn = BTN_LEFT
+	case "right":
+		btn = BTN_RIGHT
+	case "middle":
+		btn = BTN_MIDDLE
+	default:
+		return fmt.Errorf("unknown mouse button: %s", button)
+	}
+
+	if err := vid.sendAbsEvent(EV_KEY, btn, 1); err != nil {
+		return err
+	}
+	if err := vid.sendAbsEvent(EV_ABS, ABS_X, x); err != nil {
+		return err
+	}
+	if err := vid.sendAbsEvent(EV_ABS, ABS_Y, y); err != nil {
+		return err
+	}
+	if err := vid.sendAbsEvent(EV_KEY, btn, 0); err != nil {
+		return err
+	}
+	return vid.sendAbsEvent(EV_SYN, 0, 0)
+}
+
+func mouseMoveToHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MouseAbsoluteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err := device.MoveMouseAbsolute(req.X, req.Y); err != nil {
+		respondJSON(w, Response{false, "Failed to move mouse: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Moved mouse to (%d, %d)", req.X, req.Y)}, http.StatusOK)
+}
+
+func mouseDragHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MouseDragRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.Button == "" {
+		req.Button = "left"
+	}
+
+	if err := device.DragMouse(req.Button, req.X, req.Y); err != nil {
+		respondJSON(w, Response{false, "Failed to drag mouse: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Dragged %s mouse button to (%d, %d)", req.Button, req.X, req.Y)}, http.StatusOK)
+}
+
+// configHandler lets a client report the compositor's logical screen
+// size so absolute coordinates line up with the real display.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.ScreenWidth <= 0 || req.ScreenHeight <= 0 {
+		respondJSON(w, Response{false, "screen_width and screen_height must be positive"}, http.StatusBadRequest)
+		return
+	}
+
+	newAbsFd, err := createAbsolutePointerDevice(req.ScreenWidth, req.ScreenHeight)
+	if err != nil {
+		respondJSON(w, Response{false, "Failed to reconfigure pointer device: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	device.absMu.Lock()
+	oldAbsFd := device.absFd
+	device.absFd = newAbsFd
+	device.screenWidth = req.ScreenWidth
+	device.screenHeight = req.ScreenHeight
+	device.absMu.Unlock()
+
+	ioctl(oldAbsFd, UI_DEV_DESTROY, 0)
+	syscall.Close(oldAbsFd)
+
+	respondJSON(w, Response{true, fmt.Sprintf("Configured screen size to %dx%d", req.ScreenWidth, req.ScreenHeight)}, http.StatusOK)
+}
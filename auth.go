@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TLSConfig points at a certificate/key pair to serve over HTTPS
+// instead of plain HTTP.
+type TLSConfig struct {
+	Cert string `toml:"cert"`
+	Key  string `toml:"key"`
+}
+
+// TokenConfig is one bearer token's capabilities: which scopes it may
+// call, and (for key-pressing scopes) which keys it's allowed to send.
+type TokenConfig struct {
+	Token       string   `toml:"token"`
+	Scopes      []string `toml:"scopes"`
+	AllowedKeys []string `toml:"allowed_keys"`
+}
+
+// Config is the server's access-control file, loaded from
+// ~/.config/keyboard-server/config.toml.
+type Config struct {
+	TLS    TLSConfig     `toml:"tls"`
+	Tokens []TokenConfig `toml:"tokens"`
+}
+
+func defaultConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "keyboard-server", "config.toml"), nil
+}
+
+// LoadConfig reads the access-control config. A missing file isn't an
+// error: it just means no tokens are configured, so requireScope lets
+// every request through, matching this server's historical wide-open
+// localhost behavior.
+func LoadConfig(path string) (*Config, error) {
+	var cfg Config
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &cfg, nil
+	}
+
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) tokenFor(raw string) (*TokenConfig, bool) {
+	for i := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(c.Tokens[i].Token), []byte(raw)) == 1 {
+			return &c.Tokens[i], true
+		}
+	}
+	return nil, false
+}
+
+// hasScope checks an exact scope match or its wildcard form, e.g. a
+// token granted "macro:*" satisfies a route requiring "macro:play".
+func (t *TokenConfig) hasScope(scope string) bool {
+	wildcard := strings.SplitN(scope, ":", 2)[0] + ":*"
+	for _, s := range t.Scopes {
+		if s == scope || s == wildcard || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsKeys reports whether every key in keys is on this token's
+// allowlist. An empty allowlist means no extra restriction beyond scope.
+func (t *TokenConfig) AllowsKeys(keys []string) bool {
+	if len(t.AllowedKeys) == 0 {
+		return true
+	}
+	allowed := make(map[string]bool, len(t.AllowedKeys))
+	for _, k := range t.AllowedKeys {
+		allowed[strings.ToLower(k)] = true
+	}
+	for _, k := range keys {
+		if !allowed[strings.ToLower(k)] {
+			return false
+		}
+	}
+	return true
+}
+
+type contextKey string
+
+const tokenContextKey contextKey = "keyboard-server-token"
+
+// requireScope wraps next so it only runs once the request's bearer
+// token is verified against scope.
+func requireScope(cfg *Config, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(cfg.Tokens) == 0 {
+			next(w, r)
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		raw := strings.TrimPrefix(header, "Bearer ")
+		if raw == header {
+			respondJSON(w, Response{false, "Missing bearer token"}, http.StatusUnauthorized)
+			return
+		}
+
+		token, ok := cfg.tokenFor(raw)
+		if !ok || !token.hasScope(scope) {
+			respondJSON(w, Response{false, "Token lacks required scope: " + scope}, http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey, token)))
+	}
+}
+
+func tokenFromRequest(r *http.Request) *TokenConfig {
+	token, _ := r.Context().Value(tokenContextKey).(*TokenConfig)
+	return token
+}
+
+// keysAllowed checks the request's authenticated token (if any) against
+// its key allowlist. Requests made with auth disabled always pass.
+func keysAllowed(r *http.Request, keys []string) bool {
+	token := tokenFromRequest(r)
+	if token == nil {
+		return true
+	}
+	return token.AllowsKeys(keys)
+}
@@ -0,0 +1,199 @@
+// Package hyprland is a minimal client for Hyprland's IPC sockets,
+// letting the keyboard server condition input routing on the active
+// window and workspace instead of acting as a dumb uinput proxy.
+package hyprland
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ActiveWindow describes the window Hyprland currently considers
+// focused, as reported by the "activewindow" command/event.
+type ActiveWindow struct {
+	Class     string
+	Title     string
+	Workspace int
+}
+
+// Client talks to a running Hyprland compositor over its two Unix
+// sockets: .socket.sock for request/response commands and .socket2.sock
+// for a stream of newline-delimited events.
+type Client struct {
+	socketDir string
+
+	mu     sync.RWMutex
+	active ActiveWindow
+
+	listeners []func(ActiveWindow)
+}
+
+// NewClient locates the compositor's IPC sockets under
+// $XDG_RUNTIME_DIR/hypr/$HYPRLAND_INSTANCE_SIGNATURE.
+func NewClient() (*Client, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if runtimeDir == "" || signature == "" {
+		return nil, fmt.Errorf("XDG_RUNTIME_DIR and HYPRLAND_INSTANCE_SIGNATURE must be set")
+	}
+
+	return &Client{socketDir: filepath.Join(runtimeDir, "hypr", signature)}, nil
+}
+
+func (c *Client) commandSocketPath() string {
+	return filepath.Join(c.socketDir, ".socket.sock")
+}
+
+func (c *Client) eventSocketPath() string {
+	return filepath.Join(c.socketDir, ".socket2.sock")
+}
+
+// command sends a raw request over .socket.sock and returns the
+// compositor's response.
+func (c *Client) command(req string) (string, error) {
+	conn, err := net.Dial("unix", c.commandSocketPath())
+	if err != nil {
+		return "", fmt.Errorf("failed to dial hyprland command socket: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return "", fmt.Errorf("failed to send command: %v", err)
+	}
+
+	buf := make([]byte, 0, 4096)
+	chunk := make([]byte, 4096)
+	for {
+		n, err := conn.Read(chunk)
+		if n > 0 {
+			buf = append(buf, chunk[:n]...)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return string(buf), nil
+}
+
+// ActiveWindow queries the compositor for the currently focused window.
+func (c *Client) ActiveWindow() (ActiveWindow, error) {
+	raw, err := c.command("j/activewindow")
+	if err != nil {
+		return ActiveWindow{}, err
+	}
+	return parseActiveWindow(raw), nil
+}
+
+// parseActiveWindow pulls the handful of fields we care about out of
+// Hyprland's JSON reply without pulling in a JSON decoder dependency
+// for three fields.
+func parseActiveWindow(raw string) ActiveWindow {
+	var win ActiveWindow
+	win.Class = extractJSONString(raw, "class")
+	win.Title = extractJSONString(raw, "title")
+	if ws := extractJSONNumber(raw, "id"); ws != "" {
+		if n, err := strconv.Atoi(ws); err == nil {
+			win.Workspace = n
+		}
+	}
+	return win
+}
+
+func extractJSONString(raw, key string) string {
+	marker := "\"" + key + "\":\""
+	idx := strings.Index(raw, marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := raw[idx+len(marker):]
+	end := strings.Index(rest, "\"")
+	if end < 0 {
+		return ""
+	}
+	return rest[:end]
+}
+
+func extractJSONNumber(raw, key string) string {
+	marker := "\"" + key + "\":"
+	idx := strings.Index(raw, marker)
+	if idx < 0 {
+		return ""
+	}
+	rest := raw[idx+len(marker):]
+	end := strings.IndexAny(rest, ",}")
+	if end < 0 {
+		return ""
+	}
+	return strings.TrimSpace(rest[:end])
+}
+
+// OnActiveWindowChange registers a callback invoked whenever an
+// "activewindow" or "workspace" event arrives on the event socket.
+func (c *Client) OnActiveWindowChange(fn func(ActiveWindow)) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners, fn)
+	c.mu.Unlock()
+}
+
+// Current returns the most recently observed active window, as tracked
+// from the event stream rather than a fresh query.
+func (c *Client) Current() ActiveWindow {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// Subscribe connects to .socket2.sock and dispatches "activewindow" /
+// "workspace" events to registered listeners until the connection
+// closes. It blocks, so callers run it in a goroutine.
+func (c *Client) Subscribe() error {
+	conn, err := net.Dial("unix", c.eventSocketPath())
+	if err != nil {
+		return fmt.Errorf("failed to dial hyprland event socket: %v", err)
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, payload, ok := strings.Cut(line, ">>")
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "activewindow":
+			fields := strings.SplitN(payload, ",", 2)
+			win := ActiveWindow{Class: fields[0]}
+			if len(fields) > 1 {
+				win.Title = fields[1]
+			}
+			c.mu.Lock()
+			win.Workspace = c.active.Workspace
+			c.active = win
+			listeners := append([]func(ActiveWindow){}, c.listeners...)
+			c.mu.Unlock()
+			for _, listener := range listeners {
+				listener(win)
+			}
+		case "workspace":
+			if n, err := strconv.Atoi(payload); err == nil {
+				c.mu.Lock()
+				c.active.Workspace = n
+				win := c.active
+				listeners := append([]func(ActiveWindow){}, c.listeners...)
+				c.mu.Unlock()
+				for _, listener := range listeners {
+					listener(win)
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/RadoslavTsvetanov/keyboard-server-for-hyprland/hyprland"
+)
+
+// WindowGuard is an optional predicate attached to key/mouse requests
+// that restricts dispatch to a particular compositor context.
+type WindowGuard struct {
+	Class     string `json:"class,omitempty"`
+	Workspace int    `json:"workspace,omitempty"`
+}
+
+// KeyBinding rewrites an incoming key combo to a different one while a
+// WindowGuard matches, registered through POST /bind.
+type KeyBinding struct {
+	When WindowGuard `json:"when"`
+	From []string    `json:"from"`
+	To   []string    `json:"to"`
+}
+
+var (
+	hyprlandClient *hyprland.Client
+
+	bindMu   sync.RWMutex
+	bindings []KeyBinding
+)
+
+// initHyprland connects to the compositor's event socket if one is
+// available; the server still works as a plain uinput proxy when it
+// isn't (e.g. not running under Hyprland, or during local testing).
+func initHyprland() {
+	client, err := hyprland.NewClient()
+	if err != nil {
+		log.Printf("hyprland: IPC unavailable, window guards disabled: %v", err)
+		return
+	}
+
+	hyprlandClient = client
+	go func() {
+		if err := client.Subscribe(); err != nil {
+			log.Printf("hyprland: event subscription ended: %v", err)
+		}
+	}()
+}
+
+// guardPasses reports whether guard is satisfied by the compositor's
+// current active window. A nil guard always passes.
+func guardPasses(guard *WindowGuard) bool {
+	if guard == nil {
+		return true
+	}
+	if hyprlandClient == nil {
+		return false
+	}
+
+	current := hyprlandClient.Current()
+	if guard.Class != "" && !strings.EqualFold(guard.Class, current.Class) {
+		return false
+	}
+	if guard.Workspace != 0 && guard.Workspace != current.Workspace {
+		return false
+	}
+	return true
+}
+
+// applyBindings rewrites keys if a registered binding's guard matches
+// the current window and its From combo matches keys exactly. Bindings
+// are matched against the compositor's live state (via guardPasses),
+// not the caller-supplied request guard, which is already enforced
+// separately before this is called.
+func applyBindings(keys []string) []string {
+	bindMu.RLock()
+	defer bindMu.RUnlock()
+
+	for _, binding := range bindings {
+		if !guardPasses(&binding.When) {
+			continue
+		}
+		if keyComboEquals(binding.From, keys) {
+			return binding.To
+		}
+	}
+	return keys
+}
+
+func keyComboEquals(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func bindHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var binding KeyBinding
+	if err := json.NewDecoder(r.Body).Decode(&binding); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if len(binding.From) == 0 || len(binding.To) == 0 {
+		respondJSON(w, Response{false, "Both from and to key combos are required"}, http.StatusBadRequest)
+		return
+	}
+
+	bindMu.Lock()
+	bindings = append(bindings, binding)
+	bindMu.Unlock()
+
+	respondJSON(w, Response{true, fmt.Sprintf("Registered binding %v -> %v", binding.From, binding.To)}, http.StatusOK)
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// macroRecord is one captured InputEvent, stored as newline-delimited
+// JSON so macros stay diffable and hand-editable.
+type macroRecord struct {
+	TimeNs int64  `json:"t_ns"`
+	Type   uint16 `json:"type"`
+	Code   uint16 `json:"code"`
+	Value  int32  `json:"value"`
+}
+
+// MacroRecorder captures raw evdev events from a real input device and
+// can replay captured macros through a VirtualInputDevice.
+type MacroRecorder struct {
+	device *VirtualInputDevice
+
+	mu          sync.Mutex
+	recordingID string
+	recordingFd int
+	stopCh      chan struct{}
+	doneCh      chan struct{}
+	events      []macroRecord
+
+	macros map[string][]macroRecord
+}
+
+func NewMacroRecorder(device *VirtualInputDevice) *MacroRecorder {
+	return &MacroRecorder{
+		device: device,
+		macros: make(map[string][]macroRecord),
+	}
+}
+
+func macroDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve home directory: %v", err)
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+
+	dir := filepath.Join(base, "keyboard-server", "macros")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create macro directory: %v", err)
+	}
+	return dir, nil
+}
+
+// StartRecording opens an evdev device node and streams its events into
+// a channel, batching them into m.events. Recording and playback never
+// touch the same fd, so the HTTP handlers stay non-blocking.
+func (m *MacroRecorder) StartRecording(devicePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.recordingFd != 0 {
+		return fmt.Errorf("a recording is already in progress (id %s)", m.recordingID)
+	}
+
+	fd, err := syscall.Open(devicePath, syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", devicePath, err)
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 36)
+	events := make(chan InputEvent, 64)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	go readEvdevEvents(fd, events, stopCh)
+	go m.collect(events, doneCh)
+
+	m.recordingID = id
+	m.recordingFd = fd
+	m.stopCh = stopCh
+	m.doneCh = doneCh
+	m.events = nil
+
+	return nil
+}
+
+// readEvdevEvents polls a non-blocking evdev fd in the style of
+// shuttle-go's evdev.Open loop, pushing each decoded InputEvent onto
+// events until stopCh is closed. The fd is opened O_NONBLOCK so a
+// pending Read always returns (with EAGAIN when nothing is ready)
+// instead of blocking past a stop request.
+func readEvdevEvents(fd int, events chan<- InputEvent, stopCh <-chan struct{}) {
+	defer close(events)
+	defer syscall.Close(fd)
+
+	var buf [unsafe.Sizeof(InputEvent{})]byte
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		n, err := syscall.Read(fd, buf[:])
+		if err == syscall.EAGAIN || err == syscall.EWOULDBLOCK {
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+		if err != nil || n != len(buf) {
+			return
+		}
+
+		events <- *(*InputEvent)(unsafe.Pointer(&buf[0]))
+	}
+}
+
+func (m *MacroRecorder) collect(events <-chan InputEvent, doneCh chan<- struct{}) {
+	defer close(doneCh)
+	for ev := range events {
+		m.mu.Lock()
+		m.events = append(m.events, macroRecord{
+			TimeNs: time.Now().UnixNano(),
+			Type:   ev.Type,
+			Code:   ev.Code,
+			Value:  ev.Value,
+		})
+		m.mu.Unlock()
+	}
+}
+
+// StopRecording closes the evdev fd, waits for the collector to drain,
+// and persists the captured events to disk.
+func (m *MacroRecorder) StopRecording() (string, error) {
+	m.mu.Lock()
+	if m.recordingFd == 0 {
+		m.mu.Unlock()
+		return "", fmt.Errorf("no recording in progress")
+	}
+	id := m.recordingID
+	stopCh := m.stopCh
+	doneCh := m.doneCh
+	m.mu.Unlock()
+
+	close(stopCh)
+	<-doneCh
+
+	m.mu.Lock()
+	recorded := m.events
+	m.macros[id] = recorded
+	m.recordingFd = 0
+	m.recordingID = ""
+	m.events = nil
+	m.mu.Unlock()
+
+	if err := m.persist(id, recorded); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (m *MacroRecorder) persist(id string, records []macroRecord) error {
+	dir, err := macroDir()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, id+".ndjson")
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create macro file: %v", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write macro record: %v", err)
+		}
+	}
+	return nil
+}
+
+// validMacroID rejects ids that could escape the macros directory when
+// joined into a file path (e.g. "../../../etc/passwd").
+func validMacroID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\") && id != "." && id != ".."
+}
+
+func (m *MacroRecorder) load(id string) ([]macroRecord, error) {
+	if !validMacroID(id) {
+		return nil, fmt.Errorf("invalid macro id: %q", id)
+	}
+
+	m.mu.Lock()
+	if records, ok := m.macros[id]; ok {
+		m.mu.Unlock()
+		return records, nil
+	}
+	m.mu.Unlock()
+
+	dir, err := macroDir()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, id+".ndjson"))
+	if err != nil {
+		return nil, fmt.Errorf("macro %s not found: %v", id, err)
+	}
+	defer f.Close()
+
+	var records []macroRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record macroRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse macro %s: %v", id, err)
+		}
+		records = append(records, record)
+	}
+
+	m.mu.Lock()
+	m.macros[id] = records
+	m.mu.Unlock()
+
+	return records, nil
+}
+
+// Play replays a macro through the virtual input device, preserving
+// inter-event timing scaled by speed. loop repeats the macro count times
+// (0 means play once). token is the caller's authenticated token (nil
+// when auth is disabled); its key allowlist is enforced against every
+// EV_KEY record, using the name keyNameForCode gives the record's code,
+// as keysAllowed does for /hold and /press, since replay would
+// otherwise bypass AllowsKeys entirely.
+func (m *MacroRecorder) Play(id string, speed float64, loop int, token *TokenConfig) error {
+	records, err := m.load(id)
+	if err != nil {
+		return err
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	if loop <= 0 {
+		loop = 1
+	}
+
+	if token != nil {
+		for _, record := range records {
+			if record.Type != EV_KEY {
+				continue
+			}
+			keyName := keyNameForCode(record.Code)
+			if !token.AllowsKeys([]string{keyName}) {
+				return fmt.Errorf("token is not allowed to send key %q (code %d)", keyName, record.Code)
+			}
+		}
+	}
+
+	for i := 0; i < loop; i++ {
+		var lastNs int64
+		for j, record := range records {
+			if j > 0 {
+				delta := time.Duration(float64(record.TimeNs-lastNs) / speed)
+				if delta > 0 {
+					time.Sleep(delta)
+				}
+			}
+			lastNs = record.TimeNs
+
+			if err := m.device.sendEvent(record.Type, record.Code, record.Value); err != nil {
+				return fmt.Errorf("failed to replay event: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// List returns the IDs of every macro persisted to disk.
+func (m *MacroRecorder) List() ([]string, error) {
+	dir, err := macroDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list macros: %v", err)
+	}
+
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if ext := filepath.Ext(name); ext == ".ndjson" {
+			ids = append(ids, name[:len(name)-len(ext)])
+		}
+	}
+	return ids, nil
+}
+
+type macroRecordStartRequest struct {
+	Device string `json:"device"`
+}
+
+type macroPlayRequest struct {
+	ID    string  `json:"id"`
+	Speed float64 `json:"speed"`
+	Loop  int     `json:"loop"`
+}
+
+var macroRecorder *MacroRecorder
+
+func macroRecordStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req macroRecordStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.Device == "" {
+		respondJSON(w, Response{false, "No device specified"}, http.StatusBadRequest)
+		return
+	}
+
+	if err := macroRecorder.StartRecording(req.Device); err != nil {
+		respondJSON(w, Response{false, "Failed to start recording: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, "Recording started"}, http.StatusOK)
+}
+
+func macroRecordStopHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := macroRecorder.StopRecording()
+	if err != nil {
+		respondJSON(w, Response{false, "Failed to stop recording: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Recorded macro %s", id)}, http.StatusOK)
+}
+
+func macroPlayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req macroPlayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.ID == "" {
+		respondJSON(w, Response{false, "No macro id specified"}, http.StatusBadRequest)
+		return
+	}
+
+	if err := macroRecorder.Play(req.ID, req.Speed, req.Loop, tokenFromRequest(r)); err != nil {
+		respondJSON(w, Response{false, "Failed to play macro: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Played macro %s", req.ID)}, http.StatusOK)
+}
+
+func macroListHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ids, err := macroRecorder.List()
+	if err != nil {
+		respondJSON(w, Response{false, "Failed to list macros: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"macros": ids})
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 	"unsafe"
@@ -107,7 +108,13 @@ const (
 	KEY_PAGEUP       = 104
 	KEY_PAGEDOWN     = 109
 	KEY_INSERT       = 110
-	
+
+	// Media keys
+	KEY_MUTE         = 113
+	KEY_VOLUMEDOWN   = 114
+	KEY_VOLUMEUP     = 115
+	KEY_PLAYPAUSE    = 164
+
 	// Mouse buttons
 	BTN_LEFT   = 0x110
 	BTN_RIGHT  = 0x111
@@ -148,13 +155,22 @@ type InputID struct {
 }
 
 type VirtualInputDevice struct {
-	fd int
+	fd     int
 	keyMap map[string]uint16
+
+	// absMu guards absFd/screenWidth/screenHeight, which configHandler
+	// swaps out at runtime while other goroutines may concurrently be
+	// sending absolute-pointer events.
+	absMu        sync.RWMutex
+	absFd        int
+	screenWidth  int32
+	screenHeight int32
 }
 
 // HTTP request structures
 type KeyRequest struct {
-	Keys []string `json:"keys"`
+	Keys []string     `json:"keys"`
+	When *WindowGuard `json:"when,omitempty"`
 }
 
 type MouseRequest struct {
@@ -163,7 +179,8 @@ type MouseRequest struct {
 }
 
 type MouseClickRequest struct {
-	Button string `json:"button"` // "left", "right", "middle"
+	Button string       `json:"button"` // "left", "right", "middle"
+	When   *WindowGuard `json:"when,omitempty"`
 }
 
 type MouseScrollRequest struct {
@@ -215,6 +232,7 @@ func NewVirtualInputDevice() (*VirtualInputDevice, error) {
 		KEY_UP, KEY_DOWN, KEY_LEFT, KEY_RIGHT,
 		KEY_LEFTMETA, KEY_RIGHTMETA, KEY_RIGHTCTRL, KEY_RIGHTALT,
 		KEY_DELETE, KEY_HOME, KEY_END, KEY_PAGEUP, KEY_PAGEDOWN, KEY_INSERT,
+		KEY_MUTE, KEY_VOLUMEDOWN, KEY_VOLUMEUP, KEY_PLAYPAUSE,
 		BTN_LEFT, BTN_RIGHT, BTN_MIDDLE,
 	}
 
@@ -299,9 +317,25 @@ func NewVirtualInputDevice() (*VirtualInputDevice, error) {
 		
 		// Mouse buttons
 		"leftclick": BTN_LEFT, "rightclick": BTN_RIGHT, "middleclick": BTN_MIDDLE,
+
+		// Media keys
+		"mute": KEY_MUTE, "volumedown": KEY_VOLUMEDOWN, "volumeup": KEY_VOLUMEUP,
+		"playpause": KEY_PLAYPAUSE,
+	}
+
+	absFd, err := createAbsolutePointerDevice(defaultScreenWidth, defaultScreenHeight)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
 	}
 
-	return &VirtualInputDevice{fd: fd, keyMap: keyMap}, nil
+	return &VirtualInputDevice{
+		fd:           fd,
+		absFd:        absFd,
+		keyMap:       keyMap,
+		screenWidth:  defaultScreenWidth,
+		screenHeight: defaultScreenHeight,
+	}, nil
 }
 
 func (vid *VirtualInputDevice) sendEvent(eventType, code uint16, value int32) error {
@@ -395,6 +429,9 @@ func (vid *VirtualInputDevice) ScrollMouse(direction int32) error {
 }
 
 func (vid *VirtualInputDevice) Close() error {
+	ioctl(vid.absFd, UI_DEV_DESTROY, 0)
+	syscall.Close(vid.absFd)
+
 	ioctl(vid.fd, UI_DEV_DESTROY, 0)
 	return syscall.Close(vid.fd)
 }
@@ -437,6 +474,17 @@ func holdHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !guardPasses(req.When) {
+		respondJSON(w, Response{false, "Guard condition not met"}, http.StatusConflict)
+		return
+	}
+	req.Keys = applyBindings(req.Keys)
+
+	if !keysAllowed(r, req.Keys) {
+		respondJSON(w, Response{false, "Token is not allowed to send one or more of these keys"}, http.StatusForbidden)
+		return
+	}
+
 	if err := device.HoldKeys(req.Keys); err != nil {
 		respondJSON(w, Response{false, "Failed to hold keys: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -462,6 +510,17 @@ func releaseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !guardPasses(req.When) {
+		respondJSON(w, Response{false, "Guard condition not met"}, http.StatusConflict)
+		return
+	}
+	req.Keys = applyBindings(req.Keys)
+
+	if !keysAllowed(r, req.Keys) {
+		respondJSON(w, Response{false, "Token is not allowed to send one or more of these keys"}, http.StatusForbidden)
+		return
+	}
+
 	if err := device.ReleaseKeys(req.Keys); err != nil {
 		respondJSON(w, Response{false, "Failed to release keys: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -487,6 +546,17 @@ func pressHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !guardPasses(req.When) {
+		respondJSON(w, Response{false, "Guard condition not met"}, http.StatusConflict)
+		return
+	}
+	req.Keys = applyBindings(req.Keys)
+
+	if !keysAllowed(r, req.Keys) {
+		respondJSON(w, Response{false, "Token is not allowed to send one or more of these keys"}, http.StatusForbidden)
+		return
+	}
+
 	if err := device.PressKeys(req.Keys); err != nil {
 		respondJSON(w, Response{false, "Failed to press keys: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -531,6 +601,11 @@ func mouseClickHandler(w http.ResponseWriter, r *http.Request) {
 		req.Button = "left"
 	}
 
+	if !guardPasses(req.When) {
+		respondJSON(w, Response{false, "Guard condition not met"}, http.StatusConflict)
+		return
+	}
+
 	if err := device.ClickMouse(req.Button); err != nil {
 		respondJSON(w, Response{false, "Failed to click mouse: " + err.Error()}, http.StatusInternalServerError)
 		return
@@ -574,12 +649,23 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 		"status": "running",
 		"available_keys": keys,
 		"endpoints": map[string]string{
-			"hold":         "POST /hold - Hold keys down",
-			"release":      "POST /release - Release held keys",
-			"press":        "POST /press - Press and release keys",
-			"mouse/move":   "POST /mouse/move - Move mouse",
-			"mouse/click":  "POST /mouse/click - Click mouse button",
-			"mouse/scroll": "POST /mouse/scroll - Scroll mouse wheel",
+			"hold":                "POST /hold - Hold keys down",
+			"release":             "POST /release - Release held keys",
+			"press":               "POST /press - Press and release keys",
+			"mouse/move":          "POST /mouse/move - Move mouse",
+			"mouse/click":         "POST /mouse/click - Click mouse button",
+			"mouse/scroll":        "POST /mouse/scroll - Scroll mouse wheel",
+			"webrtc/offer":        "POST /webrtc/offer - Negotiate a keyboard/mouse data-channel session",
+			"type":                "POST /type - Type a Unicode string",
+			"type/chord":          "POST /type/chord - Type an xdotool-style key chord",
+			"mouse/moveto":        "POST /mouse/moveto - Move mouse to an absolute position",
+			"mouse/drag":          "POST /mouse/drag - Press, drag to a position, and release",
+			"config":              "POST /config - Set the compositor's logical screen size",
+			"macro/record/start":  "POST /macro/record/start - Start recording input from a device",
+			"macro/record/stop":   "POST /macro/record/stop - Stop recording and save the macro",
+			"macro/play":          "POST /macro/play - Replay a recorded macro",
+			"macro/list":          "GET /macro/list - List recorded macros",
+			"bind":                "POST /bind - Register a context-aware key rebinding",
 		},
 	}
 
@@ -602,16 +688,50 @@ func main() {
 	}
 	defer device.Close()
 
-	// Setup HTTP routes
-	http.HandleFunc("/hold", holdHandler)
-	http.HandleFunc("/release", releaseHandler)
-	http.HandleFunc("/press", pressHandler)
-	http.HandleFunc("/mouse/move", mouseMoveHandler)
-	http.HandleFunc("/mouse/click", mouseClickHandler)
-	http.HandleFunc("/mouse/scroll", mouseScrollHandler)
+	configPath, err := defaultConfigPath()
+	if err != nil {
+		log.Fatalf("Failed to resolve config path: %v", err)
+	}
+	if p := os.Getenv("KEYBOARD_SERVER_CONFIG"); p != "" {
+		configPath = p
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Tokens) == 0 {
+		log.Printf("No tokens configured in %s — running without authentication", configPath)
+	}
+
+	// Setup HTTP routes, each wrapped with the scope its action requires
+	http.HandleFunc("/hold", requireScope(cfg, "keys:hold", holdHandler))
+	http.HandleFunc("/release", requireScope(cfg, "keys:hold", releaseHandler))
+	http.HandleFunc("/press", requireScope(cfg, "keys:press", pressHandler))
+	http.HandleFunc("/mouse/move", requireScope(cfg, "mouse:move", mouseMoveHandler))
+	http.HandleFunc("/mouse/click", requireScope(cfg, "mouse:click", mouseClickHandler))
+	http.HandleFunc("/mouse/scroll", requireScope(cfg, "mouse:move", mouseScrollHandler))
 	http.HandleFunc("/status", statusHandler)
 	http.HandleFunc("/", statusHandler) // Root shows status
 
+	webrtcTransport := NewWebRTCTransport(device)
+	http.HandleFunc("/webrtc/offer", requireScope(cfg, "keys:hold", webrtcTransport.offerHandler))
+
+	macroRecorder = NewMacroRecorder(device)
+	http.HandleFunc("/macro/record/start", requireScope(cfg, "macro:record", macroRecordStartHandler))
+	http.HandleFunc("/macro/record/stop", requireScope(cfg, "macro:record", macroRecordStopHandler))
+	http.HandleFunc("/macro/play", requireScope(cfg, "macro:play", macroPlayHandler))
+	http.HandleFunc("/macro/list", requireScope(cfg, "macro:list", macroListHandler))
+
+	initHyprland()
+	http.HandleFunc("/bind", requireScope(cfg, "keys:hold", bindHandler))
+
+	http.HandleFunc("/type", requireScope(cfg, "keys:press", typeStringHandler))
+	http.HandleFunc("/type/chord", requireScope(cfg, "keys:press", typeChordHandler))
+
+	http.HandleFunc("/mouse/moveto", requireScope(cfg, "mouse:move", mouseMoveToHandler))
+	http.HandleFunc("/mouse/drag", requireScope(cfg, "mouse:move", mouseDragHandler))
+	http.HandleFunc("/config", requireScope(cfg, "mouse:move", configHandler))
+
 	// Setup signal handling
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -637,6 +757,25 @@ func main() {
 	log.Printf("  POST /mouse/click - Click mouse button")
 	log.Printf("  POST /mouse/scroll - Scroll mouse wheel")
 	log.Printf("  GET  /status - Show status and available keys")
+	log.Printf("  POST /webrtc/offer - Negotiate a keyboard/mouse data-channel session")
+	log.Printf("  POST /type - Type a Unicode string")
+	log.Printf("  POST /type/chord - Type an xdotool-style key chord")
+	log.Printf("  POST /mouse/moveto - Move mouse to an absolute position")
+	log.Printf("  POST /mouse/drag - Press, drag to a position, and release")
+	log.Printf("  POST /config - Set the compositor's logical screen size")
+	log.Printf("  POST /macro/record/start - Start recording input from a device")
+	log.Printf("  POST /macro/record/stop - Stop recording and save the macro")
+	log.Printf("  POST /macro/play - Replay a recorded macro")
+	log.Printf("  GET  /macro/list - List recorded macros")
+	log.Printf("  POST /bind - Register a context-aware key rebinding")
+
+	if cfg.TLS.Cert != "" && cfg.TLS.Key != "" {
+		log.Printf("Serving over TLS using %s / %s", cfg.TLS.Cert, cfg.TLS.Key)
+		if err := http.ListenAndServeTLS("0.0.0.0:"+port, cfg.TLS.Cert, cfg.TLS.Key, nil); err != nil {
+			log.Fatalf("Server failed: %v", err)
+		}
+		return
+	}
 
 	if err := http.ListenAndServe("0.0.0.0:"+port, nil); err != nil {
 		log.Fatalf("Server failed: %v", err)
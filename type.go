@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTypeDelay is the pause between synthesized keystrokes. Some
+// consumers (terminal emulators, remote desktops) drop events that
+// arrive faster than a real keyboard could produce them.
+const defaultTypeDelay = 12 * time.Millisecond
+
+// shiftedDigits maps each symbol produced by a shifted digit-row key to
+// that digit's keyMap entry, matching a standard US keyboard layout.
+var shiftedDigits = map[rune]string{
+	'!': "1", '@': "2", '#': "3", '$': "4", '%': "5",
+	'^': "6", '&': "7", '*': "8", '(': "9", ')': "0",
+}
+
+// shiftedPunctuation maps shifted punctuation to the unshifted key that
+// produces it.
+var shiftedPunctuation = map[rune]string{
+	':': "semicolon", '"': "apostrophe", '<': "comma", '>': "dot",
+	'?': "slash", '_': "minus", '+': "equal", '{': "leftbrace",
+	'}': "rightbrace", '|': "backslash", '~': "grave",
+}
+
+// unshiftedPunctuation maps ordinary (non-shifted) punctuation runes to
+// their keyMap entry.
+var unshiftedPunctuation = map[rune]string{
+	'-': "minus", ',': "comma", '.': "dot", ';': "semicolon",
+	'\'': "apostrophe", '`': "grave", '\\': "backslash", '=': "equal",
+}
+
+// chordAliases maps xdotool-style token spellings to keyMap entries
+// that are otherwise only reachable by their lowercase name.
+var chordAliases = map[string]string{
+	"return":  "enter",
+	"escape":  "esc",
+	"ctl":     "ctrl",
+	"control": "ctrl",
+}
+
+type TypeStringRequest struct {
+	Text string `json:"text"`
+}
+
+type TypeChordRequest struct {
+	Chord string `json:"chord"`
+}
+
+// TypeString walks the runes of text and emits the keycode sequence
+// needed to reproduce it, pressing KEY_LEFTSHIFT around uppercase
+// letters and shifted symbols. Runes with no keyMap entry are
+// collected and returned as a single error rather than silently
+// skipped.
+func (vid *VirtualInputDevice) TypeString(text string) error {
+	var unmapped []rune
+
+	for _, r := range text {
+		keyName, needsShift, ok := vid.resolveRune(r)
+		if !ok {
+			unmapped = append(unmapped, r)
+			continue
+		}
+
+		if needsShift {
+			if err := vid.HoldKeys([]string{"shift"}); err != nil {
+				return err
+			}
+		}
+		if err := vid.PressKeys([]string{keyName}); err != nil {
+			return err
+		}
+		if needsShift {
+			if err := vid.ReleaseKeys([]string{"shift"}); err != nil {
+				return err
+			}
+		}
+
+		time.Sleep(defaultTypeDelay)
+	}
+
+	if len(unmapped) > 0 {
+		return fmt.Errorf("no key mapping for runes: %q", string(unmapped))
+	}
+	return nil
+}
+
+// resolveRune finds the keyMap entry (and required shift state) for a
+// single rune of typed text.
+func (vid *VirtualInputDevice) resolveRune(r rune) (keyName string, needsShift bool, ok bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return string(r), false, true
+	case r >= 'A' && r <= 'Z':
+		return strings.ToLower(string(r)), true, true
+	case r == ' ':
+		return "space", false, true
+	case r >= '0' && r <= '9':
+		return string(r), false, true
+	}
+
+	if digit, ok := shiftedDigits[r]; ok {
+		return digit, true, true
+	}
+	if key, ok := shiftedPunctuation[r]; ok {
+		return key, true, true
+	}
+	if key, ok := unshiftedPunctuation[r]; ok {
+		return key, false, true
+	}
+	if _, exists := vid.keyMap[string(r)]; exists {
+		return string(r), false, true
+	}
+
+	return "", false, false
+}
+
+// TypeChord parses xdotool-style chord syntax ("ctrl+shift+t",
+// "super+Return") and presses the resolved keys together: hold in
+// order, sync, release in reverse order.
+func (vid *VirtualInputDevice) TypeChord(spec string) error {
+	tokens := strings.Split(spec, "+")
+	if len(tokens) == 0 {
+		return fmt.Errorf("empty chord spec")
+	}
+
+	var keys []string
+	var unmapped []string
+	for _, token := range tokens {
+		name := strings.ToLower(strings.TrimSpace(token))
+		if alias, ok := chordAliases[name]; ok {
+			name = alias
+		}
+		if _, exists := vid.keyMap[name]; !exists {
+			unmapped = append(unmapped, token)
+			continue
+		}
+		keys = append(keys, name)
+	}
+
+	if len(unmapped) > 0 {
+		return fmt.Errorf("no key mapping for chord tokens: %v", unmapped)
+	}
+
+	if err := vid.HoldKeys(keys); err != nil {
+		return err
+	}
+
+	time.Sleep(defaultTypeDelay)
+
+	reversed := make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+	return vid.ReleaseKeys(reversed)
+}
+
+func typeStringHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TypeStringRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.Text == "" {
+		respondJSON(w, Response{false, "No text specified"}, http.StatusBadRequest)
+		return
+	}
+
+	if err := device.TypeString(req.Text); err != nil {
+		respondJSON(w, Response{false, "Failed to type text: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Typed %d characters", len([]rune(req.Text)))}, http.StatusOK)
+}
+
+func typeChordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TypeChordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if req.Chord == "" {
+		respondJSON(w, Response{false, "No chord specified"}, http.StatusBadRequest)
+		return
+	}
+
+	if err := device.TypeChord(req.Chord); err != nil {
+		respondJSON(w, Response{false, "Failed to type chord: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, Response{true, fmt.Sprintf("Typed chord: %s", req.Chord)}, http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Binary framing for data-channel input events:
+//
+//	byte 0    : event type (EV_KEY or EV_REL; other types are rejected)
+//	byte 1..2 : code (uint16, little-endian)
+//	byte 3..6 : value (int32, little-endian)
+const webrtcFrameSize = 1 + 2 + 4
+
+// webrtcOfferRequest is the SDP offer posted by a browser client.
+type webrtcOfferRequest struct {
+	SDP  string `json:"sdp"`
+	Type string `json:"type"`
+}
+
+// WebRTCTransport negotiates peer connections carrying "keyboard" and
+// "mouse" data channels that feed directly into a VirtualInputDevice,
+// bypassing the HTTP request/response round-trip used by the rest of
+// the server.
+type WebRTCTransport struct {
+	device *VirtualInputDevice
+	api    *webrtc.API
+	config webrtc.Configuration
+}
+
+func NewWebRTCTransport(device *VirtualInputDevice) *WebRTCTransport {
+	return &WebRTCTransport{
+		device: device,
+		api:    webrtc.NewAPI(),
+		config: webrtc.Configuration{
+			ICEServers: []webrtc.ICEServer{
+				{URLs: []string{"stun:stun.l.google.com:19302"}},
+			},
+		},
+	}
+}
+
+func (t *WebRTCTransport) offerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req webrtcOfferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, Response{false, "Invalid JSON: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	pc, err := t.api.NewPeerConnection(t.config)
+	if err != nil {
+		respondJSON(w, Response{false, "Failed to create peer connection: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	// The token authorized at offer time scopes every frame for the
+	// lifetime of this peer connection — there's no per-message
+	// Authorization header on a data channel.
+	token := tokenFromRequest(r)
+
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		switch dc.Label() {
+		case "keyboard", "mouse":
+			dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+				if err := t.dispatch(msg.Data, token); err != nil {
+					log.Printf("webrtc: dropping malformed frame on %s: %v", dc.Label(), err)
+				}
+			})
+		default:
+			log.Printf("webrtc: ignoring unexpected data channel %q", dc.Label())
+		}
+	})
+
+	offer := webrtc.SessionDescription{Type: webrtc.NewSDPType(req.Type), SDP: req.SDP}
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		respondJSON(w, Response{false, "Failed to set remote description: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		respondJSON(w, Response{false, "Failed to create answer: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		respondJSON(w, Response{false, "Failed to set local description: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	<-gatherComplete
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pc.LocalDescription())
+}
+
+// isMouseButtonCode reports whether code is one of the BTN_* codes
+// surfaced through keyMap, as opposed to an ordinary keyboard key.
+func isMouseButtonCode(code uint16) bool {
+	switch code {
+	case BTN_LEFT, BTN_RIGHT, BTN_MIDDLE:
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatch decodes one framed InputEvent and routes it to the virtual
+// input device, mirroring the HTTP handlers' HoldKeys/ReleaseKeys/
+// MoveMouse/ScrollMouse calls; mouse buttons arrive as EV_KEY frames and
+// are held/released through HoldKeys/ReleaseKeys like any other key,
+// not through ClickMouse. token is the bearer token
+// that negotiated this peer connection (nil when auth is disabled). A
+// data channel has no per-message Authorization header, so token's
+// scopes and key allowlist are enforced here on every frame, exactly
+// as requireScope/keysAllowed do for the equivalent HTTP routes.
+func (t *WebRTCTransport) dispatch(frame []byte, token *TokenConfig) error {
+	if len(frame) != webrtcFrameSize {
+		return fmt.Errorf("expected %d byte frame, got %d", webrtcFrameSize, len(frame))
+	}
+
+	eventType := frame[0]
+	code := binary.LittleEndian.Uint16(frame[1:3])
+	value := int32(binary.LittleEndian.Uint32(frame[3:7]))
+
+	switch eventType {
+	case EV_KEY:
+		keyName := keyNameForCode(code)
+		if keyName == "" {
+			return fmt.Errorf("no key name registered for code %d", code)
+		}
+		if isMouseButtonCode(code) {
+			if token != nil && !token.hasScope("mouse:click") {
+				return fmt.Errorf("token lacks mouse:click scope")
+			}
+		} else if token != nil && !token.AllowsKeys([]string{keyName}) {
+			return fmt.Errorf("token is not allowed to send key %q", keyName)
+		}
+		if value != 0 {
+			return t.device.HoldKeys([]string{keyName})
+		}
+		return t.device.ReleaseKeys([]string{keyName})
+	case EV_REL:
+		if token != nil && !token.hasScope("mouse:move") {
+			return fmt.Errorf("token lacks mouse:move scope")
+		}
+		switch code {
+		case REL_X:
+			return t.device.MoveMouse(value, 0)
+		case REL_Y:
+			return t.device.MoveMouse(0, value)
+		case REL_WHEEL:
+			return t.device.ScrollMouse(value)
+		default:
+			return fmt.Errorf("unsupported relative axis %d", code)
+		}
+	default:
+		return fmt.Errorf("unsupported event type %d", eventType)
+	}
+}
+
+// keyNameForCode reverse-looks-up a keyMap entry by keycode. keyMap is a
+// Go map, so for a code with several names (e.g. "ctrl"/"leftctrl") which
+// of them is returned is not defined. It translates inbound data-channel
+// frames and replayed macro records back into the string vocabulary
+// HoldKeys/ReleaseKeys and AllowsKeys understand.
+func keyNameForCode(code uint16) string {
+	for name, key := range device.keyMap {
+		if key == code {
+			return name
+		}
+	}
+	return ""
+}